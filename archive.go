@@ -0,0 +1,115 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// archiveRequest 是POST /archive请求体的结构
+type archiveRequest struct {
+	IDs  []string `json:"ids"`
+	Name string   `json:"name"`
+}
+
+// archiveHandler 将多个文件打包为ZIP，直接流式写入响应，不在内存中缓冲整个包
+func archiveHandler(w http.ResponseWriter, r *http.Request) {
+	var ids []string
+	var name string
+
+	switch r.Method {
+	case "POST":
+		var req archiveRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+		ids = req.IDs
+		name = req.Name
+	case "GET":
+		idsParam := r.URL.Query().Get("ids")
+		if idsParam == "" {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+		ids = strings.Split(idsParam, ",")
+		name = r.URL.Query().Get("name")
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	if len(ids) == 0 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if name == "" {
+		name = "bundle.zip"
+	}
+
+	// 先查询元数据并累加总大小，超出限制时直接拒绝，避免白白打包
+	metas := make([]*FileMetadata, 0, len(ids))
+	var totalSize int64
+	for _, id := range ids {
+		meta, err := store.Get(id)
+		if err != nil {
+			fmt.Println("无法查询文件元数据：", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if meta == nil {
+			http.Error(w, fmt.Sprintf("文件不存在：%s", id), http.StatusBadRequest)
+			return
+		}
+		metas = append(metas, meta)
+		totalSize += meta.Size
+	}
+
+	if config.MaxArchiveBytes > 0 && totalSize > config.MaxArchiveBytes {
+		http.Error(w, "请求打包的文件总大小超出限制", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", name))
+
+	shaped := newRateLimitedResponseWriter(w, config.Limits.DownloadRateKbps)
+	zipWriter := zip.NewWriter(shaped)
+	defer zipWriter.Close()
+
+	flusher, canFlush := shaped.(http.Flusher)
+
+	for _, meta := range metas {
+		blobPath := blobPathForHash(meta.Hash)
+		f, err := os.Open(blobPath)
+		if err != nil {
+			fmt.Println("无法打开文件：", err)
+			return
+		}
+
+		entry, err := zipWriter.Create(meta.Filename)
+		if err != nil {
+			fmt.Println("无法创建ZIP条目：", err)
+			f.Close()
+			return
+		}
+
+		if _, err := io.Copy(entry, f); err != nil {
+			fmt.Println("无法写入ZIP条目：", err)
+			f.Close()
+			return
+		}
+		f.Close()
+
+		zipWriter.Flush()
+		if canFlush {
+			flusher.Flush()
+		}
+	}
+
+	logEvent("archive.download", "ids", ids, "filename", name, "bytes", totalSize)
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// AuthConfig 定义上传/管理操作所需的令牌
+type AuthConfig struct {
+	UploadTokens []string `yaml:"upload_tokens"`
+	AdminTokens  []string `yaml:"admin_tokens"`
+}
+
+// LimitsConfig 定义文件大小、带宽与请求频率限制
+type LimitsConfig struct {
+	UploadRateKbps         int `yaml:"upload_rate_kbps"`
+	DownloadRateKbps       int `yaml:"download_rate_kbps"`
+	MaxFileSizeMB          int `yaml:"max_file_size_mb"`
+	RequestsPerMinutePerIP int `yaml:"requests_per_minute_per_ip"`
+}
+
+// hasValidToken 在Authorization: Bearer头或表单字段"token"中查找令牌，并校验是否在允许列表中。
+// 优先检查请求头，避免仅为了校验令牌就去解析（并缓冲）整个请求体
+func hasValidToken(r *http.Request, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	token := ""
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+	if token == "" {
+		token = r.FormValue("token")
+	}
+	if token == "" {
+		return false
+	}
+
+	for _, t := range allowed {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
+
+// newRateLimitedReader 按指定kbps对读取速度做限流，kbps<=0时不限流
+func newRateLimitedReader(r io.Reader, kbps int) io.Reader {
+	if kbps <= 0 {
+		return r
+	}
+	bytesPerSec := kbps * 1024 / 8
+	return &rateLimitedReader{r: r, limiter: rate.NewLimiter(rate.Limit(bytesPerSec), bytesPerSec)}
+}
+
+type rateLimitedReader struct {
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+func (rl *rateLimitedReader) Read(p []byte) (int, error) {
+	n, err := rl.r.Read(p)
+	if n > 0 {
+		if waitErr := waitN(rl.limiter, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// rateLimitedResponseWriter 按指定kbps对响应体写入做限流
+type rateLimitedResponseWriter struct {
+	http.ResponseWriter
+	limiter *rate.Limiter
+}
+
+func newRateLimitedResponseWriter(w http.ResponseWriter, kbps int) http.ResponseWriter {
+	if kbps <= 0 {
+		return w
+	}
+	bytesPerSec := kbps * 1024 / 8
+	return &rateLimitedResponseWriter{ResponseWriter: w, limiter: rate.NewLimiter(rate.Limit(bytesPerSec), bytesPerSec)}
+}
+
+func (rw *rateLimitedResponseWriter) Write(p []byte) (int, error) {
+	if err := waitN(rw.limiter, len(p)); err != nil {
+		return 0, err
+	}
+	return rw.ResponseWriter.Write(p)
+}
+
+// Flush 转发给底层ResponseWriter，使其在被限流包装后仍支持http.Flusher（archive下载的流式分块发送依赖此接口）
+func (rw *rateLimitedResponseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// waitN 按令牌桶消耗n个字节的配额，超过桶容量（burst）时分批等待，
+// 避免单次io.Copy缓冲区（默认32KiB）大于低带宽桶容量时直接报错
+func waitN(limiter *rate.Limiter, n int) error {
+	burst := limiter.Burst()
+	for n > 0 {
+		chunk := n
+		if chunk > burst {
+			chunk = burst
+		}
+		if err := limiter.WaitN(context.Background(), chunk); err != nil {
+			return err
+		}
+		n -= chunk
+	}
+	return nil
+}
+
+// ipRequestWindow 维护每个IP最近一分钟内的请求时间戳，用于限流
+type ipRequestWindow struct {
+	mu       sync.Mutex
+	requests map[string][]time.Time
+}
+
+var requestWindow = newIPRequestWindow()
+
+func newIPRequestWindow() *ipRequestWindow {
+	w := &ipRequestWindow{requests: make(map[string][]time.Time)}
+	go w.evictLoop()
+	return w
+}
+
+// evictLoop 定期清理一分钟之前的请求记录，避免map无限增长
+func (w *ipRequestWindow) evictLoop() {
+	ticker := time.NewTicker(time.Minute)
+	for range ticker.C {
+		cutoff := time.Now().Add(-time.Minute)
+		w.mu.Lock()
+		for ip, times := range w.requests {
+			kept := filterAfter(times, cutoff)
+			if len(kept) == 0 {
+				delete(w.requests, ip)
+			} else {
+				w.requests[ip] = kept
+			}
+		}
+		w.mu.Unlock()
+	}
+}
+
+// allow 判断该IP在当前分钟窗口内是否还有请求配额
+func (w *ipRequestWindow) allow(ip string, limit int) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	cutoff := now.Add(-time.Minute)
+	kept := filterAfter(w.requests[ip], cutoff)
+
+	if len(kept) >= limit {
+		w.requests[ip] = kept
+		return false
+	}
+
+	w.requests[ip] = append(kept, now)
+	return true
+}
+
+func filterAfter(times []time.Time, cutoff time.Time) []time.Time {
+	kept := times[:0]
+	for _, t := range times {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+// rateLimitMiddleware 对每个远程IP做每分钟请求数限流，超出后返回429
+func rateLimitMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := config.Limits.RequestsPerMinutePerIP
+		if limit > 0 {
+			ip := clientIP(r)
+			if !requestWindow.allow(ip, limit) {
+				w.Header().Set("Retry-After", "60")
+				http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
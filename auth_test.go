@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// TestHasValidTokenPrefersHeaderOverForm验证Authorization头优先于表单字段，
+// 且在头部已给出合法令牌时不需要解析表单（从而避免缓冲请求体）
+func TestHasValidTokenPrefersHeaderOverForm(t *testing.T) {
+	allowed := []string{"good-token"}
+
+	req, err := http.NewRequest("POST", "/upload", strings.NewReader(url.Values{
+		"token": {"good-token"},
+	}.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer wrong-token")
+
+	if hasValidToken(req, allowed) {
+		t.Fatal("header令牌错误时即便表单令牌正确也不应通过校验")
+	}
+}
+
+// TestHasValidTokenFallsBackToForm验证没有Authorization头时仍能从表单字段取令牌
+func TestHasValidTokenFallsBackToForm(t *testing.T) {
+	allowed := []string{"good-token"}
+
+	req, err := http.NewRequest("POST", "/upload", strings.NewReader(url.Values{
+		"token": {"good-token"},
+	}.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if !hasValidToken(req, allowed) {
+		t.Fatal("没有Authorization头时应当回退到表单字段中的令牌")
+	}
+}
+
+// TestHasValidTokenEmptyAllowListPassesThrough验证未配置令牌列表时直接放行
+func TestHasValidTokenEmptyAllowListPassesThrough(t *testing.T) {
+	req, err := http.NewRequest("POST", "/upload", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !hasValidToken(req, nil) {
+		t.Fatal("未配置允许列表时应当直接放行")
+	}
+}
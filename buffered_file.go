@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bufio"
+	"os"
+)
+
+const defaultStreamBufferKB = 64
+
+// bufferedFile 包装一个*os.File，用bufio.Reader缓冲顺序读取，同时保留Seek能力
+// 供http.ServeContent处理Range请求，Seek后会重置缓冲区以保证数据一致
+type bufferedFile struct {
+	f  *os.File
+	br *bufio.Reader
+}
+
+func newBufferedFile(f *os.File, bufferKB int) *bufferedFile {
+	if bufferKB <= 0 {
+		bufferKB = defaultStreamBufferKB
+	}
+	return &bufferedFile{
+		f:  f,
+		br: bufio.NewReaderSize(f, bufferKB*1024),
+	}
+}
+
+func (b *bufferedFile) Read(p []byte) (int, error) {
+	return b.br.Read(p)
+}
+
+func (b *bufferedFile) Seek(offset int64, whence int) (int64, error) {
+	pos, err := b.f.Seek(offset, whence)
+	if err != nil {
+		return pos, err
+	}
+	b.br.Reset(b.f)
+	return pos, nil
+}
+
+func (b *bufferedFile) Close() error {
+	return b.f.Close()
+}
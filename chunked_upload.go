@@ -0,0 +1,382 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	chunkTmpDir    = "uploads/tmp"
+	chunkStateFile = "uploads/tmp/state.json"
+)
+
+// ChunkUpload 记录一次分片上传的进度信息，mu保护ReceivedChunks/Size/TotalSize的并发读写
+type ChunkUpload struct {
+	mu             sync.Mutex
+	Filename       string       `json:"filename"`
+	Size           int64        `json:"size"`
+	TotalSize      int64        `json:"total_size"`
+	ChunksTotal    int          `json:"chunks_total"`
+	ReceivedChunks map[int]bool `json:"received_chunks"`
+}
+
+// chunkManager 管理所有进行中的分片上传，状态以JSON形式持久化，便于服务重启后继续上传
+type chunkManager struct {
+	uploads sync.Map // uploadID -> *ChunkUpload
+	saveMu  sync.Mutex
+}
+
+var chunks = &chunkManager{}
+
+func init() {
+	if err := os.MkdirAll(chunkTmpDir, os.ModePerm); err != nil {
+		fmt.Println("无法创建分片临时目录：", err)
+		return
+	}
+	chunks.load()
+}
+
+// load 从磁盘恢复分片上传状态
+func (m *chunkManager) load() {
+	content, err := os.ReadFile(chunkStateFile)
+	if err != nil {
+		return
+	}
+
+	raw := make(map[string]*ChunkUpload)
+	if err := json.Unmarshal(content, &raw); err != nil {
+		fmt.Println("无法解析分片上传状态：", err)
+		return
+	}
+
+	for id, upload := range raw {
+		m.uploads.Store(id, upload)
+	}
+}
+
+// save 将当前分片上传状态写入磁盘
+func (m *chunkManager) save() {
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+
+	raw := make(map[string]json.RawMessage)
+	var marshalErr error
+	m.uploads.Range(func(key, value interface{}) bool {
+		upload := value.(*ChunkUpload)
+		upload.mu.Lock()
+		entry, err := json.Marshal(upload)
+		upload.mu.Unlock()
+		if err != nil {
+			marshalErr = err
+			return false
+		}
+		raw[key.(string)] = entry
+		return true
+	})
+
+	content, err := json.Marshal(raw)
+	if marshalErr != nil {
+		err = marshalErr
+	}
+	if err != nil {
+		fmt.Println("无法序列化分片上传状态：", err)
+		return
+	}
+
+	if err := os.WriteFile(chunkStateFile, content, 0666); err != nil {
+		fmt.Println("无法保存分片上传状态：", err)
+	}
+}
+
+func (m *chunkManager) get(uploadID string) (*ChunkUpload, bool) {
+	value, ok := m.uploads.Load(uploadID)
+	if !ok {
+		return nil, false
+	}
+	return value.(*ChunkUpload), true
+}
+
+// isSafeUploadID 要求uploadID是客户端一开始生成的合法UUID，避免被用于拼接任意文件路径
+func isSafeUploadID(uploadID string) bool {
+	_, err := uuid.Parse(uploadID)
+	return err == nil
+}
+
+// uploadChunkHandler 接收单个分片，写入 uploads/tmp/<upload-id>/<index>
+func uploadChunkHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !hasValidToken(r, config.Auth.UploadTokens) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	uploadID := r.Header.Get("X-Upload-Id")
+	if !isSafeUploadID(uploadID) {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	chunkIndex, err := strconv.Atoi(r.Header.Get("X-Chunk-Index"))
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	chunkTotal, err := strconv.Atoi(r.Header.Get("X-Chunk-Total"))
+	if err != nil || chunkTotal <= 0 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	// 客户端声明的文件总大小，必须在每个分片都带上；完成合并时若请求未带size查询参数，
+	// 就以此值校验，因此合并时总有一个声明大小可供校验，不能靠省略声明来绕过
+	totalSize, err := strconv.ParseInt(r.Header.Get("X-Total-Size"), 10, 64)
+	if err != nil || totalSize <= 0 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	maxBytes := int64(defaultMaxUploadBytes)
+	if config.Limits.MaxFileSizeMB > 0 {
+		maxBytes = int64(config.Limits.MaxFileSizeMB) * 1024 * 1024
+	}
+	if totalSize > maxBytes {
+		http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+
+	encodedName := r.Header.Get("X-File-Name")
+	nameBytes, err := base64.StdEncoding.DecodeString(encodedName)
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	filename := string(nameBytes)
+
+	uploadDir := filepath.Join(chunkTmpDir, uploadID)
+	if err := os.MkdirAll(uploadDir, os.ModePerm); err != nil {
+		fmt.Println("无法创建分片目录：", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	chunkPath := filepath.Join(uploadDir, strconv.Itoa(chunkIndex))
+	f, err := os.OpenFile(chunkPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		fmt.Println("无法创建分片文件：", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	reader := newRateLimitedReader(r.Body, config.Limits.UploadRateKbps)
+	written, err := io.Copy(f, reader)
+	if err != nil {
+		fmt.Println("无法写入分片文件：", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	actual, _ := chunks.uploads.LoadOrStore(uploadID, &ChunkUpload{
+		Filename:       filename,
+		ChunksTotal:    chunkTotal,
+		ReceivedChunks: make(map[int]bool),
+	})
+	upload := actual.(*ChunkUpload)
+
+	upload.mu.Lock()
+	if upload.TotalSize == 0 {
+		upload.TotalSize = totalSize
+	}
+	upload.ReceivedChunks[chunkIndex] = true
+	upload.Size += written
+	upload.mu.Unlock()
+
+	chunks.save()
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// completeUploadHandler 按顺序拼接所有分片，生成最终文件
+func completeUploadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !hasValidToken(r, config.Auth.UploadTokens) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	uploadID := r.Header.Get("X-Upload-Id")
+	if !isSafeUploadID(uploadID) {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	upload, ok := chunks.get(uploadID)
+	if !ok {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	upload.mu.Lock()
+	filename := upload.Filename
+	chunksTotal := upload.ChunksTotal
+	receivedChunks := make(map[int]bool, len(upload.ReceivedChunks))
+	for k, v := range upload.ReceivedChunks {
+		receivedChunks[k] = v
+	}
+	declaredTotalSize := upload.TotalSize
+	upload.mu.Unlock()
+
+	declaredSize, err := strconv.ParseInt(r.URL.Query().Get("size"), 10, 64)
+	if err != nil {
+		declaredSize = declaredTotalSize
+	}
+	if declaredSize <= 0 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	uploadDir := filepath.Join(chunkTmpDir, uploadID)
+
+	// 先写入临时文件，边拼接边计算内容哈希，和uploadHandler的做法保持一致
+	tmpFile, err := ioutil.TempFile(uploadsDir, "upload-*.tmp")
+	if err != nil {
+		fmt.Println("无法创建临时文件：", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+
+	hasher := md5.New()
+	var total int64
+	for i := 0; i < chunksTotal; i++ {
+		if !receivedChunks[i] {
+			tmpFile.Close()
+			http.Error(w, fmt.Sprintf("缺少分片 %d", i), http.StatusBadRequest)
+			return
+		}
+
+		chunkPath := filepath.Join(uploadDir, strconv.Itoa(i))
+		chunkFile, err := os.Open(chunkPath)
+		if err != nil {
+			fmt.Println("无法打开分片文件：", err)
+			tmpFile.Close()
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		written, err := io.Copy(io.MultiWriter(tmpFile, hasher), chunkFile)
+		chunkFile.Close()
+		if err != nil {
+			fmt.Println("无法拼接分片文件：", err)
+			tmpFile.Close()
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		total += written
+	}
+	tmpFile.Close()
+
+	if total != declaredSize {
+		http.Error(w, "文件大小校验失败", http.StatusBadRequest)
+		return
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))
+
+	// 按内容哈希存放，走和uploadHandler一致的去重路径
+	blobDir := filepath.Join(uploadsDir, hash[:2])
+	blobPath := blobPathForHash(hash)
+	if _, err := os.Stat(blobPath); os.IsNotExist(err) {
+		if err := os.MkdirAll(blobDir, os.ModePerm); err != nil {
+			fmt.Println("无法创建存储目录：", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if err := os.Rename(tmpFile.Name(), blobPath); err != nil {
+			fmt.Println("无法写入文件：", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(filename))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	fileID := generateUUID()
+	meta := &FileMetadata{
+		ID:         fileID,
+		Filename:   filename,
+		Hash:       hash,
+		Size:       total,
+		MimeType:   contentType,
+		UploadedAt: time.Now(),
+	}
+	if err := store.Put(meta); err != nil {
+		fmt.Println("无法保存文件元数据：", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := os.RemoveAll(uploadDir); err != nil {
+		fmt.Println("无法清理分片临时目录：", err)
+	}
+	chunks.uploads.Delete(uploadID)
+	chunks.save()
+
+	logEvent("file.upload", "file_id", fileID, "filename", filename, "upload_id", uploadID, "hash", hash, "bytes", total)
+
+	fileURL := fmt.Sprintf("/file/%s", fileID)
+	fmt.Fprintf(w, `{"url":"%s"}`, fileURL)
+}
+
+// uploadProgressHandler 返回某次分片上传的当前进度
+func uploadProgressHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.NotFound(w, r)
+		return
+	}
+
+	uploadID := r.URL.Query().Get("id")
+	upload, ok := chunks.get(uploadID)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	upload.mu.Lock()
+	received := upload.Size
+	total := upload.TotalSize
+	if total == 0 {
+		total = received
+	}
+	chunksDone := len(upload.ReceivedChunks)
+	chunksTotal := upload.ChunksTotal
+	upload.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"received_bytes":%d,"total_bytes":%d,"chunks_done":%d,"chunks_total":%d}`,
+		received, total, chunksDone, chunksTotal)
+}
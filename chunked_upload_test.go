@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+)
+
+// withChunkTestEnv在临时目录里搭建uploads/目录和元数据存储，供分片上传相关测试复用
+func withChunkTestEnv(t *testing.T) {
+	t.Helper()
+
+	dir := t.TempDir()
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(cwd) })
+
+	if err := os.MkdirAll(chunkTmpDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := openMetadataStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.db.Close() })
+	store = s
+
+	config = Config{}
+	chunks = &chunkManager{}
+	appLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func postChunk(t *testing.T, uploadID string, index, total int, totalSize int64, filename string, data []byte) {
+	t.Helper()
+
+	req := httptest.NewRequest("POST", "/upload/chunk", bytes.NewReader(data))
+	req.Header.Set("X-Upload-Id", uploadID)
+	req.Header.Set("X-Chunk-Index", strconv.Itoa(index))
+	req.Header.Set("X-Chunk-Total", strconv.Itoa(total))
+	req.Header.Set("X-File-Name", base64.StdEncoding.EncodeToString([]byte(filename)))
+	if totalSize > 0 {
+		req.Header.Set("X-Total-Size", strconv.FormatInt(totalSize, 10))
+	}
+
+	w := httptest.NewRecorder()
+	uploadChunkHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("分片%d上传失败，状态码=%d，body=%s", index, w.Code, w.Body.String())
+	}
+}
+
+// TestCompleteUploadHandlerStoresMetadataAndBlob验证/upload/complete按内容哈希落盘并写入元数据，
+// 使其和uploadHandler一样能在/list和GET /file/<id>中查到
+func TestCompleteUploadHandlerStoresMetadataAndBlob(t *testing.T) {
+	withChunkTestEnv(t)
+
+	uploadID := "11111111-1111-1111-1111-111111111111"
+	part1 := []byte("hello ")
+	part2 := []byte("world")
+	content := append(append([]byte{}, part1...), part2...)
+
+	postChunk(t, uploadID, 0, 2, int64(len(content)), "greeting.txt", part1)
+	postChunk(t, uploadID, 1, 2, int64(len(content)), "greeting.txt", part2)
+
+	req := httptest.NewRequest("POST", "/upload/complete?size="+strconv.Itoa(len(content)), nil)
+	req.Header.Set("X-Upload-Id", uploadID)
+	w := httptest.NewRecorder()
+	completeUploadHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("合并失败，状态码=%d，body=%s", w.Code, w.Body.String())
+	}
+
+	hashSum := md5.Sum(content)
+	wantHash := hex.EncodeToString(hashSum[:])
+
+	meta, err := store.FindByHash(wantHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta == nil {
+		t.Fatal("合并完成后未写入元数据，/list和GET /file/<id>将无法找到该文件")
+	}
+	if meta.Filename != "greeting.txt" || meta.Size != int64(len(content)) {
+		t.Fatalf("元数据内容不正确：%+v", meta)
+	}
+
+	blobPath := blobPathForHash(wantHash)
+	got, err := os.ReadFile(blobPath)
+	if err != nil {
+		t.Fatalf("未能在blobPathForHash指向的位置读到合并后的文件：%v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("合并后的文件内容不正确：got %q want %q", got, content)
+	}
+
+	if _, ok := chunks.get(uploadID); ok {
+		t.Fatal("上传完成后分片状态应当被清理")
+	}
+}
+
+// TestUploadChunkHandlerRequiresTotalSize验证客户端必须声明总大小，不能省略X-Total-Size来绕过完成时的大小校验
+func TestUploadChunkHandlerRequiresTotalSize(t *testing.T) {
+	withChunkTestEnv(t)
+
+	req := httptest.NewRequest("POST", "/upload/chunk", bytes.NewReader([]byte("data")))
+	req.Header.Set("X-Upload-Id", "33333333-3333-3333-3333-333333333333")
+	req.Header.Set("X-Chunk-Index", "0")
+	req.Header.Set("X-Chunk-Total", "1")
+	req.Header.Set("X-File-Name", base64.StdEncoding.EncodeToString([]byte("a.txt")))
+
+	w := httptest.NewRecorder()
+	uploadChunkHandler(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("缺少X-Total-Size时应返回400，实际状态码=%d", w.Code)
+	}
+}
+
+// TestCompleteUploadHandlerRejectsSizeMismatch验证声明大小与实际拼接结果不一致时拒绝落盘
+func TestCompleteUploadHandlerRejectsSizeMismatch(t *testing.T) {
+	withChunkTestEnv(t)
+
+	uploadID := "22222222-2222-2222-2222-222222222222"
+	postChunk(t, uploadID, 0, 1, 100, "file.bin", []byte("short"))
+
+	req := httptest.NewRequest("POST", "/upload/complete?size=100", nil)
+	req.Header.Set("X-Upload-Id", uploadID)
+	w := httptest.NewRecorder()
+	completeUploadHandler(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("声明大小不匹配时应返回400，实际状态码=%d", w.Code)
+	}
+
+	files, err := store.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Fatalf("大小校验失败时不应写入元数据，实际已有%d条", len(files))
+	}
+}
+
+// TestIsSafeUploadID验证只有合法UUID才会被接受，避免uploadID被用于拼接任意路径
+func TestIsSafeUploadID(t *testing.T) {
+	cases := []struct {
+		id   string
+		want bool
+	}{
+		{"11111111-1111-1111-1111-111111111111", true},
+		{"", false},
+		{"../../etc/passwd", false},
+		{"not-a-uuid", false},
+	}
+
+	for _, c := range cases {
+		if got := isSafeUploadID(c.id); got != c.want {
+			t.Errorf("isSafeUploadID(%q) = %v, want %v", c.id, got, c.want)
+		}
+	}
+}
+
+// TestChunkManagerSaveLoadRoundTrip验证分片上传状态能完整地序列化到磁盘并在重启后恢复
+func TestChunkManagerSaveLoadRoundTrip(t *testing.T) {
+	withChunkTestEnv(t)
+
+	uploadID := "44444444-4444-4444-4444-444444444444"
+	upload := &ChunkUpload{
+		Filename:       "resume.bin",
+		Size:           10,
+		TotalSize:      20,
+		ChunksTotal:    2,
+		ReceivedChunks: map[int]bool{0: true},
+	}
+	chunks.uploads.Store(uploadID, upload)
+	chunks.save()
+
+	restored := &chunkManager{}
+	restored.load()
+
+	got, ok := restored.get(uploadID)
+	if !ok {
+		t.Fatal("重新加载后未找到之前保存的上传状态")
+	}
+	if got.Filename != upload.Filename || got.Size != upload.Size ||
+		got.TotalSize != upload.TotalSize || got.ChunksTotal != upload.ChunksTotal {
+		t.Fatalf("恢复的状态不匹配：got %+v want %+v", got, upload)
+	}
+	if !got.ReceivedChunks[0] {
+		t.Fatal("恢复的状态丢失了已接收的分片记录")
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// FormatSize 将字节数转换为易读的 B/KB/MB/GB/TB 形式
+func FormatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	units := []string{"KB", "MB", "GB", "TB"}
+	return fmt.Sprintf("%.1f %s", float64(size)/float64(div), units[exp])
+}
+
+// iconClassForFilename 按扩展名返回一个图标样式类，供文件列表展示用
+func iconClassForFilename(filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+
+	switch ext {
+	case ".txt", ".json":
+		return "file-text-o"
+	case ".doc", ".docx":
+		return "file-word-o"
+	case ".xls", ".xlsx":
+		return "file-excel-o"
+	case ".ppt", ".pptx":
+		return "file-powerpoint-o"
+	case ".pdf":
+		return "file-pdf-o"
+	case ".jpg", ".jpeg", ".png", ".gif", ".bmp", ".webp", ".svg":
+		return "file-image-o"
+	case ".mp3", ".wav", ".flac", ".aac":
+		return "file-audio-o"
+	case ".mp4", ".avi", ".mov", ".mkv", ".webm":
+		return "file-video-o"
+	case ".zip", ".rar", ".7z", ".tar", ".gz":
+		return "file-archive-o"
+	case ".go", ".py", ".js", ".ts", ".java", ".c", ".cpp", ".rs", ".html", ".css":
+		return "file-code-o"
+	default:
+		return "file-o"
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+var appLogger *slog.Logger
+
+// initLogger 按配置创建slog.Logger，输出到带滚动策略的log.txt
+func initLogger() error {
+	writer, err := newRotatingWriter(logFileName, config.LogRotateMB)
+	if err != nil {
+		return err
+	}
+
+	opts := &slog.HandlerOptions{Level: parseLogLevel(config.LogLevel)}
+
+	var handler slog.Handler
+	if config.LogFormat == "text" {
+		handler = slog.NewTextHandler(writer, opts)
+	} else {
+		handler = slog.NewJSONHandler(writer, opts)
+	}
+
+	appLogger = slog.New(handler)
+	return nil
+}
+
+func parseLogLevel(level string) slog.Level {
+	switch level {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// logEvent 记录一条业务事件，args为交替的key/value对，追加在event字段之后
+func logEvent(event string, args ...any) {
+	appLogger.Info(event, append([]any{"event", event}, args...)...)
+}
+
+// rotatingWriter 是一个按大小滚动的io.Writer，超过限制时把log.txt重命名为log.txt.1
+type rotatingWriter struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	file     *os.File
+	size     int64
+}
+
+func newRotatingWriter(path string, maxMB int) (*rotatingWriter, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &rotatingWriter{
+		path:     path,
+		maxBytes: int64(maxMB) * 1024 * 1024,
+		file:     file,
+		size:     info.Size(),
+	}, nil
+}
+
+func (rw *rotatingWriter) Write(p []byte) (int, error) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if rw.maxBytes > 0 && rw.size+int64(len(p)) > rw.maxBytes {
+		if err := rw.rotate(); err != nil {
+			fmt.Println("无法滚动日志文件：", err)
+		}
+	}
+
+	n, err := rw.file.Write(p)
+	rw.size += int64(n)
+	return n, err
+}
+
+func (rw *rotatingWriter) rotate() error {
+	rw.file.Close()
+
+	backup := rw.path + ".1"
+	os.Remove(backup)
+	if err := os.Rename(rw.path, backup); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(rw.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+
+	rw.file = file
+	rw.size = 0
+	return nil
+}
+
+// statusCapturingWriter 包装http.ResponseWriter以记录最终状态码与写出的字节数
+type statusCapturingWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (s *statusCapturingWriter) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusCapturingWriter) Write(p []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(p)
+	s.bytes += n
+	return n, err
+}
+
+// Flush 转发给底层ResponseWriter，保留archive等处理器依赖的http.Flusher能力
+func (s *statusCapturingWriter) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// loggingMiddleware 为每个请求记录一条http.access事件
+func loggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		shim := &statusCapturingWriter{ResponseWriter: w}
+
+		next(shim, r)
+
+		if shim.status == 0 {
+			shim.status = http.StatusOK
+		}
+		logEvent("http.access",
+			"remote_ip", clientIP(r),
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", shim.status,
+			"bytes", shim.bytes,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"user_agent", r.UserAgent(),
+		)
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"crypto/md5"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -8,6 +10,9 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,15 +21,28 @@ import (
 
 type Config struct {
 	Port                int    `yaml:"port"`
-	LogTimestamp        bool   `yaml:"log_timestamp"`
 	CustomHTMLHead      string `yaml:"custom_html_head"`
 	CustomHTMLBody      string `yaml:"custom_html_body"`
 	EnableXSSProtection bool   `yaml:"enable_xss_protection"`
+	MaxArchiveBytes     int64  `yaml:"max_archive_bytes"`
+	StreamBufferKB      int    `yaml:"stream_buffer_kb"`
+
+	Auth   AuthConfig   `yaml:"auth"`
+	Limits LimitsConfig `yaml:"limits"`
+
+	LogFormat   string `yaml:"log_format"`
+	LogLevel    string `yaml:"log_level"`
+	LogRotateMB int    `yaml:"log_rotate_mb"`
 }
 
 type FileMetadata struct {
-	ID       string `json:"id"`
-	Filename string `json:"filename"`
+	ID            string    `json:"id"`
+	Filename      string    `json:"filename"`
+	Hash          string    `json:"hash"`
+	Size          int64     `json:"size"`
+	MimeType      string    `json:"mime_type"`
+	UploadedAt    time.Time `json:"uploaded_at"`
+	DownloadCount int       `json:"download_count"`
 }
 
 const (
@@ -52,11 +70,28 @@ func main() {
 		}
 	}
 
+	// 打开文件元数据存储
+	store, err = openMetadataStore()
+	if err != nil {
+		fmt.Println("无法打开元数据存储：", err)
+		return
+	}
+
+	// 初始化结构化日志
+	if err := initLogger(); err != nil {
+		fmt.Println("无法初始化日志：", err)
+		return
+	}
+
 	// 设置路由
-	http.HandleFunc("/", indexHandler)
-	http.HandleFunc("/upload", uploadHandler)
-	http.HandleFunc("/list", listHandler)
-	http.HandleFunc("/file/", fileHandler)
+	http.HandleFunc("/", loggingMiddleware(indexHandler))
+	http.HandleFunc("/upload", loggingMiddleware(rateLimitMiddleware(uploadHandler)))
+	http.HandleFunc("/upload/chunk", loggingMiddleware(rateLimitMiddleware(uploadChunkHandler)))
+	http.HandleFunc("/upload/complete", loggingMiddleware(rateLimitMiddleware(completeUploadHandler)))
+	http.HandleFunc("/upload/progress", loggingMiddleware(uploadProgressHandler))
+	http.HandleFunc("/list", loggingMiddleware(listHandler))
+	http.HandleFunc("/file/", loggingMiddleware(rateLimitMiddleware(fileHandler)))
+	http.HandleFunc("/archive", loggingMiddleware(rateLimitMiddleware(archiveHandler)))
 
 	// 启动服务器
 	addr := fmt.Sprintf(":%d", config.Port)
@@ -110,8 +145,23 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// defaultMaxUploadBytes 在未配置max_file_size_mb时仍然套用的兜底上限，
+// 避免未授权请求在令牌校验之前就让服务端无限缓冲请求体
+const defaultMaxUploadBytes = 1 << 30 // 1GB
+
 func uploadHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "POST" {
+		maxBytes := int64(defaultMaxUploadBytes)
+		if config.Limits.MaxFileSizeMB > 0 {
+			maxBytes = int64(config.Limits.MaxFileSizeMB) * 1024 * 1024
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+
+		if !hasValidToken(r, config.Auth.UploadTokens) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
 		// 解析文件
 		file, handler, err := r.FormFile("file")
 		if err != nil {
@@ -121,33 +171,68 @@ func uploadHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		defer file.Close()
 
-		// 创建目标文件
+		reader := newRateLimitedReader(file, config.Limits.UploadRateKbps)
+
 		filename := handler.Filename
 		fileExt := filepath.Ext(filename)
-		fileID := generateUUID() + fileExt
-		filePath := filepath.Join(uploadsDir, fileID)
-		f, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE, 0666)
+
+		// 先写入临时文件，边写边计算内容哈希
+		tmpFile, err := ioutil.TempFile(uploadsDir, "upload-*.tmp")
 		if err != nil {
-			fmt.Println("无法创建文件：", err)
+			fmt.Println("无法创建临时文件：", err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
-		defer f.Close()
+		defer os.Remove(tmpFile.Name())
 
-		// 将文件内容复制到目标文件
-		_, err = io.Copy(f, file)
+		hasher := md5.New()
+		size, err := io.Copy(io.MultiWriter(tmpFile, hasher), reader)
+		tmpFile.Close()
 		if err != nil {
 			fmt.Println("无法保存文件：", err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
+		hash := hex.EncodeToString(hasher.Sum(nil))
+
+		// 按内容哈希存放，相同内容的文件只保留一份物理blob；blob路径只由哈希决定，
+		// 不掺入当前上传的扩展名，否则同一内容换个扩展名重新上传就无法命中去重
+		blobDir := filepath.Join(uploadsDir, hash[:2])
+		blobPath := blobPathForHash(hash)
+		if _, err := os.Stat(blobPath); os.IsNotExist(err) {
+			if err := os.MkdirAll(blobDir, os.ModePerm); err != nil {
+				fmt.Println("无法创建存储目录：", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			if err := os.Rename(tmpFile.Name(), blobPath); err != nil {
+				fmt.Println("无法写入文件：", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+		}
 
-		// 记录操作日志
-		logMessage := filename
-		if config.LogTimestamp {
-			logMessage += fmt.Sprintf(" [%s]", time.Now().Format("2006-01-02 15:04:05"))
+		contentType := mime.TypeByExtension(fileExt)
+		if contentType == "" {
+			contentType = "application/octet-stream"
 		}
-		writeLog(logMessage)
+
+		fileID := generateUUID()
+		meta := &FileMetadata{
+			ID:         fileID,
+			Filename:   filename,
+			Hash:       hash,
+			Size:       size,
+			MimeType:   contentType,
+			UploadedAt: time.Now(),
+		}
+		if err := store.Put(meta); err != nil {
+			fmt.Println("无法保存文件元数据：", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		logEvent("file.upload", "file_id", fileID, "filename", filename, "hash", hash, "bytes", size)
 
 		// 生成文件的直链URL
 		fileURL := fmt.Sprintf("/file/%s", fileID)
@@ -176,108 +261,213 @@ func uploadHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func listHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "GET" {
-		// 获取文件列表
-		files, err := getFileList()
-		if err != nil {
-			fmt.Println("无法获取文件列表：", err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			return
-		}
+	if r.Method != "GET" {
+		http.NotFound(w, r)
+		return
+	}
 
-		fmt.Fprintf(w, `
-<!DOCTYPE html>
-<html>
-<head>
-    <title>文件列表</title>
-    <style>
-        %s
-        table {
-            border-collapse: collapse;
-            width: 100%;
-        }
-        th, td {
-            text-align: left;
-            padding: 8px;
-            border-bottom: 1px solid #ddd;
-        }
-        tr:hover {
-            background-color: #f5f5f5;
-        }
-        th {
-            background-color: #4CAF50;
-            color: white;
-        }
-    </style>
-</head>
-<body>
-    %s
-    <h1>文件列表</h1>
-    <table>
-        <tr>
-            <th>ID</th>
-            <th>文件名</th>
-        </tr>
-`)
+	// 获取文件列表
+	files, err := getFileList()
+	if err != nil {
+		fmt.Println("无法获取文件列表：", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	query := r.URL.Query()
+	q := strings.TrimSpace(query.Get("q"))
+	if q != "" {
+		filtered := files[:0]
 		for _, file := range files {
-			fmt.Fprintf(w, "<tr><td>%s</td><td>%s</td></tr>", file.ID, file.Filename)
+			if strings.Contains(strings.ToLower(file.Filename), strings.ToLower(q)) {
+				filtered = append(filtered, file)
+			}
 		}
-		fmt.Fprintf(w, `
-    </table>
-    <br>
-    <a href="/">返回上传页面</a>
-</body>
-</html>
-`)
-	} else {
-		// 处理其他请求（POST，PUT，DELETE等）
-		http.NotFound(w, r)
+		files = filtered
+	}
+
+	sortBy := query.Get("sort")
+	if sortBy == "" {
+		sortBy = "time"
 	}
+	order := query.Get("order")
+	if order == "" {
+		order = "desc"
+	}
+	sortFileList(files, sortBy, order)
+
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	perPage, err := strconv.Atoi(query.Get("per_page"))
+	if err != nil || perPage < 1 {
+		perPage = 20
+	}
+
+	totalPages := (len(files) + perPage - 1) / perPage
+	if totalPages < 1 {
+		totalPages = 1
+	}
+	if page > totalPages {
+		page = totalPages
+	}
+
+	start := (page - 1) * perPage
+	if start > len(files) {
+		start = len(files)
+	}
+	end := start + perPage
+	if end > len(files) {
+		end = len(files)
+	}
+	pageFiles := files[start:end]
+
+	rows := make([]fileRow, 0, len(pageFiles))
+	for _, file := range pageFiles {
+		rows = append(rows, fileRow{
+			ID:         file.ID,
+			Filename:   file.Filename,
+			SizeHuman:  FormatSize(file.Size),
+			UploadedAt: file.UploadedAt.Format("2006-01-02 15:04:05"),
+			MimeType:   file.MimeType,
+			Icon:       iconClassForFilename(file.Filename),
+		})
+	}
+
+	flip := func(column string) string {
+		if sortBy == column && order == "asc" {
+			return "desc"
+		}
+		return "asc"
+	}
+
+	data := listPageData{
+		CustomHTMLHead: config.CustomHTMLHead,
+		CustomHTMLBody: config.CustomHTMLBody,
+		Files:          rows,
+		Query:          q,
+		Sort:           sortBy,
+		Order:          order,
+		Page:           page,
+		PerPage:        perPage,
+		TotalPages:     totalPages,
+		NextOrder: map[string]string{
+			"name": flip("name"),
+			"size": flip("size"),
+			"time": flip("time"),
+		},
+	}
+
+	if err := templates.ExecuteTemplate(w, "list", data); err != nil {
+		fmt.Println("无法渲染文件列表：", err)
+	}
+}
+
+// sortFileList 按name/size/time对文件列表原地排序
+func sortFileList(files []FileMetadata, sortBy, order string) {
+	less := func(i, j int) bool {
+		var result bool
+		switch sortBy {
+		case "size":
+			result = files[i].Size < files[j].Size
+		case "name":
+			result = files[i].Filename < files[j].Filename
+		default:
+			result = files[i].UploadedAt.Before(files[j].UploadedAt)
+		}
+		if order == "desc" {
+			return !result
+		}
+		return result
+	}
+	sort.Slice(files, less)
 }
 
 func fileHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "GET" {
-		fileID := r.URL.Path[len("/file/"):]
-		filePath := filepath.Join(uploadsDir, fileID)
+	fileID := r.URL.Path[len("/file/"):]
 
-		// 检查文件是否存在
-		_, err := os.Stat(filePath)
-		if os.IsNotExist(err) {
+	switch r.Method {
+	case "GET":
+		meta, err := store.Get(fileID)
+		if err != nil {
+			fmt.Println("无法查询文件元数据：", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if meta == nil {
 			http.NotFound(w, r)
 			return
 		}
 
-		// 设置文件下载头
-		filename := filepath.Base(filePath)
-		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", filename))
+		blobPath := blobPathForHash(meta.Hash)
 
-		// 设置文件的Content-Type
-		contentType := mime.TypeByExtension(filepath.Ext(filename))
-		if contentType == "" {
-			contentType = "application/octet-stream"
+		f, err := os.Open(blobPath)
+		if err != nil {
+			http.NotFound(w, r)
+			return
 		}
-		w.Header().Set("Content-Type", contentType)
+		defer f.Close()
 
-		http.ServeFile(w, r, filePath)
-	} else {
-		// 处理其他请求（POST，PUT，DELETE等）
+		info, err := f.Stat()
+		if err != nil {
+			fmt.Println("无法读取文件信息：", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		// 下载头，默认内联预览，?download=1时才强制下载
+		disposition := "inline"
+		if r.URL.Query().Get("download") == "1" {
+			disposition = "attachment"
+		}
+		w.Header().Set("Content-Disposition", fmt.Sprintf("%s; filename=\"%s\"", disposition, meta.Filename))
+		w.Header().Set("Content-Type", meta.MimeType)
+		w.Header().Set("ETag", fmt.Sprintf("%q", meta.Hash))
+
+		if err := store.IncrementDownloadCount(fileID); err != nil {
+			fmt.Println("无法更新下载次数：", err)
+		}
+		logEvent("file.download", "file_id", fileID, "filename", meta.Filename, "hash", meta.Hash)
+
+		buffered := newBufferedFile(f, config.StreamBufferKB)
+		shaped := newRateLimitedResponseWriter(w, config.Limits.DownloadRateKbps)
+		http.ServeContent(shaped, r, meta.Filename, info.ModTime(), buffered)
+	case "DELETE":
+		if !hasValidToken(r, config.Auth.AdminTokens) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		meta, err := store.Delete(fileID)
+		if err != nil {
+			fmt.Println("无法删除文件元数据：", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if meta == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		if other, err := store.FindByHash(meta.Hash); err == nil && other == nil {
+			blobPath := blobPathForHash(meta.Hash)
+			if err := os.Remove(blobPath); err != nil && !os.IsNotExist(err) {
+				fmt.Println("无法删除文件：", err)
+			}
+		}
+		logEvent("file.delete", "file_id", fileID, "filename", meta.Filename, "hash", meta.Hash)
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
 		http.NotFound(w, r)
 	}
 }
 
-func writeLog(message string) {
-	file, err := os.OpenFile(logFileName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
-	if err != nil {
-		fmt.Println("无法写入日志文件：", err)
-		return
-	}
-	defer file.Close()
-
-	logMessage := fmt.Sprintf("%s\n", message)
-	_, err = file.WriteString(logMessage)
-	if err != nil {
-		fmt.Println("无法写入日志文件：", err)
-	}
+// blobPathForHash 根据内容哈希计算物理文件路径；路径只由哈希决定，不依赖文件扩展名，
+// 这样同一内容无论以什么扩展名重新上传都能命中去重
+func blobPathForHash(hash string) string {
+	return filepath.Join(uploadsDir, hash[:2], hash)
 }
 
 func generateUUID() string {
@@ -290,31 +480,5 @@ func generateUUID() string {
 }
 
 func getFileList() ([]FileMetadata, error) {
-	var files []FileMetadata
-
-	err := filepath.Walk(uploadsDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		if !info.IsDir() {
-			relPath, err := filepath.Rel(uploadsDir, path)
-			if err != nil {
-				return err
-			}
-			file := FileMetadata{
-				ID:       relPath,
-				Filename: filepath.Base(relPath),
-			}
-			files = append(files, file)
-		}
-
-		return nil
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	return files, nil
+	return store.All()
 }
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func postFile(t *testing.T, filename string, content []byte) *httptest.ResponseRecorder {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	part, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := part.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	w := httptest.NewRecorder()
+	uploadHandler(w, req)
+	return w
+}
+
+// TestUploadHandlerDedupesByContentHash验证相同内容换不同文件名再次上传时只保留一份物理blob，
+// 并且两条元数据记录都能解析到同一个blobPathForHash路径
+func TestUploadHandlerDedupesByContentHash(t *testing.T) {
+	withChunkTestEnv(t)
+
+	content := []byte("same bytes, different name")
+
+	w1 := postFile(t, "first.txt", content)
+	if w1.Code != http.StatusOK {
+		t.Fatalf("第一次上传失败，状态码=%d，body=%s", w1.Code, w1.Body.String())
+	}
+
+	w2 := postFile(t, "second.txt", content)
+	if w2.Code != http.StatusOK {
+		t.Fatalf("第二次上传失败，状态码=%d，body=%s", w2.Code, w2.Body.String())
+	}
+
+	files, err := store.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("应当各自生成一条元数据记录，实际有%d条", len(files))
+	}
+	if files[0].Hash != files[1].Hash {
+		t.Fatalf("相同内容的哈希应当一致：%q != %q", files[0].Hash, files[1].Hash)
+	}
+
+	blobDir := filepath.Join(uploadsDir, files[0].Hash[:2])
+	entries, err := os.ReadDir(blobDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("相同内容应当只保留一份物理blob，实际有%d个文件：%v", len(entries), entries)
+	}
+
+	got, err := os.ReadFile(blobPathForHash(files[0].Hash))
+	if err != nil {
+		t.Fatalf("未能按blobPathForHash读取blob：%v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("blob内容不正确：got %q want %q", got, content)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	metadataDBFile = "uploads/metadata.db"
+	metadataBucket = "files"
+)
+
+// metadataStore 用BoltDB持久化文件元数据，取代按目录遍历生成文件列表的方式
+type metadataStore struct {
+	db *bolt.DB
+}
+
+var store *metadataStore
+
+func openMetadataStore() (*metadataStore, error) {
+	db, err := bolt.Open(metadataDBFile, 0666, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(metadataBucket))
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &metadataStore{db: db}, nil
+}
+
+// Put 写入或更新一条文件元数据
+func (s *metadataStore) Put(meta *FileMetadata) error {
+	content, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(metadataBucket))
+		return bucket.Put([]byte(meta.ID), content)
+	})
+}
+
+// Get 按ID查询一条文件元数据
+func (s *metadataStore) Get(id string) (*FileMetadata, error) {
+	var meta *FileMetadata
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(metadataBucket))
+		content := bucket.Get([]byte(id))
+		if content == nil {
+			return nil
+		}
+
+		meta = &FileMetadata{}
+		return json.Unmarshal(content, meta)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return meta, nil
+}
+
+// Delete 删除一条文件元数据，返回被删除的记录（不存在时返回nil）
+func (s *metadataStore) Delete(id string) (*FileMetadata, error) {
+	var meta *FileMetadata
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(metadataBucket))
+		content := bucket.Get([]byte(id))
+		if content == nil {
+			return nil
+		}
+
+		meta = &FileMetadata{}
+		if err := json.Unmarshal(content, meta); err != nil {
+			return err
+		}
+		return bucket.Delete([]byte(id))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return meta, nil
+}
+
+// FindByHash 查找是否已有相同哈希的文件，用于去重
+func (s *metadataStore) FindByHash(hash string) (*FileMetadata, error) {
+	var found *FileMetadata
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(metadataBucket))
+		return bucket.ForEach(func(k, v []byte) error {
+			meta := &FileMetadata{}
+			if err := json.Unmarshal(v, meta); err != nil {
+				return err
+			}
+			if meta.Hash == hash {
+				found = meta
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return found, nil
+}
+
+// All 列出全部文件元数据
+func (s *metadataStore) All() ([]FileMetadata, error) {
+	var files []FileMetadata
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(metadataBucket))
+		return bucket.ForEach(func(k, v []byte) error {
+			meta := FileMetadata{}
+			if err := json.Unmarshal(v, &meta); err != nil {
+				return err
+			}
+			files = append(files, meta)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// IncrementDownloadCount 累加某个文件的下载次数
+func (s *metadataStore) IncrementDownloadCount(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(metadataBucket))
+		content := bucket.Get([]byte(id))
+		if content == nil {
+			return fmt.Errorf("未找到文件元数据：%s", id)
+		}
+
+		meta := &FileMetadata{}
+		if err := json.Unmarshal(content, meta); err != nil {
+			return err
+		}
+		meta.DownloadCount++
+
+		updated, err := json.Marshal(meta)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(id), updated)
+	})
+}
@@ -0,0 +1,133 @@
+package main
+
+import "html/template"
+
+var templateFuncs = template.FuncMap{
+	"seq": func(from, to int) []int {
+		if to < from {
+			return nil
+		}
+		out := make([]int, 0, to-from+1)
+		for i := from; i <= to; i++ {
+			out = append(out, i)
+		}
+		return out
+	},
+}
+
+// templates 在启动时加载一次并缓存，避免每次请求都重新解析模板
+var templates = template.Must(template.New("list").Funcs(templateFuncs).Parse(listPageTemplate))
+
+// fileRow 是渲染文件列表时每一行需要的展示数据
+type fileRow struct {
+	ID         string
+	Filename   string
+	SizeHuman  string
+	UploadedAt string
+	MimeType   string
+	Icon       string
+}
+
+// listPageData 是文件列表页模板的完整渲染数据
+type listPageData struct {
+	CustomHTMLHead string
+	CustomHTMLBody string
+	Files          []fileRow
+	Query          string
+	Sort           string
+	Order          string
+	Page           int
+	PerPage        int
+	TotalPages     int
+	NextOrder      map[string]string
+}
+
+const listPageTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+    <title>文件列表</title>
+    <style>
+        {{.CustomHTMLHead}}
+        table {
+            border-collapse: collapse;
+            width: 100%;
+        }
+        th, td {
+            text-align: left;
+            padding: 8px;
+            border-bottom: 1px solid #ddd;
+        }
+        tr:hover {
+            background-color: #f5f5f5;
+        }
+        th {
+            background-color: #4CAF50;
+            color: white;
+        }
+        .icon {
+            margin-right: 4px;
+        }
+    </style>
+</head>
+<body>
+    {{.CustomHTMLBody}}
+    <h1>文件列表</h1>
+    <form method="get" action="/list">
+        <input type="hidden" name="sort" value="{{.Sort}}">
+        <input type="hidden" name="order" value="{{.Order}}">
+        <input type="text" name="q" placeholder="按文件名搜索" value="{{.Query}}">
+        <input type="submit" value="搜索">
+    </form>
+    <form action="/archive" method="post" id="archive-form">
+    <table>
+        <tr>
+            <th></th>
+            <th><a href="/list?sort=name&order={{.NextOrder.name}}&q={{.Query}}">文件名</a></th>
+            <th><a href="/list?sort=size&order={{.NextOrder.size}}&q={{.Query}}">大小</a></th>
+            <th><a href="/list?sort=time&order={{.NextOrder.time}}&q={{.Query}}">上传时间</a></th>
+            <th>类型</th>
+        </tr>
+        {{range .Files}}
+        <tr>
+            <td><input type="checkbox" name="ids" value="{{.ID}}"></td>
+            <td><span class="icon {{.Icon}}"></span><a href="/file/{{.ID}}">{{.Filename}}</a></td>
+            <td>{{.SizeHuman}}</td>
+            <td>{{.UploadedAt}}</td>
+            <td>{{.MimeType}}</td>
+        </tr>
+        {{end}}
+    </table>
+    <br>
+    <button type="button" onclick="downloadSelected()">打包下载选中文件</button>
+    </form>
+    <br>
+    {{if gt .TotalPages 1}}
+    <div>
+        {{range $p := seq 1 .TotalPages}}
+            {{if eq $p $.Page}}
+                <strong>{{$p}}</strong>
+            {{else}}
+                <a href="/list?sort={{$.Sort}}&order={{$.Order}}&q={{$.Query}}&page={{$p}}&per_page={{$.PerPage}}">{{$p}}</a>
+            {{end}}
+        {{end}}
+    </div>
+    {{end}}
+    <br>
+    <a href="/">返回上传页面</a>
+    <script>
+    function downloadSelected() {
+        var ids = Array.prototype.map.call(
+            document.querySelectorAll('#archive-form input[name="ids"]:checked'),
+            function (el) { return el.value; }
+        );
+        if (ids.length === 0) {
+            alert('请先选择文件');
+            return;
+        }
+        window.location = '/archive?ids=' + ids.join(',') + '&name=bundle.zip';
+    }
+    </script>
+</body>
+</html>
+`